@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Archiver is the interface implemented by each supported archive format.
+// The archive_file data source picks an implementation based on its "type"
+// attribute and drives it through this interface so the resource logic
+// doesn't need to branch on format.
+type Archiver interface {
+	ArchiveContent(content []byte, infilename string) error
+	ArchiveFile(infilename string) error
+	ArchiveDir(indirname string, opts ArchiveDirOpts) error
+	ArchiveMultiple(content map[string][]byte) error
+	ArchiveRemote(url, filename string, opts RemoteOpts) error
+	ArchiveMultipleRemote(sources []RemoteSource) error
+	SetOutputFileMode(outputFileMode string)
+}
+
+// RemoteSource is one named remote artifact for Archiver.ArchiveMultipleRemote.
+type RemoteSource struct {
+	URL      string
+	Filename string
+	Opts     RemoteOpts
+}
+
+// ArchiveDirOpts customizes the behavior of Archiver.ArchiveDir.
+type ArchiveDirOpts struct {
+	Excludes                  []string
+	ExcludeSymlinkDirectories bool
+
+	// ExcludePatterns holds doublestar globs (e.g. "**/*.tfstate",
+	// "node_modules/**") matched against each entry's archive-relative,
+	// forward-slash path.
+	ExcludePatterns []string
+
+	// ExcludeFromGitignore, when true, additionally excludes paths matched
+	// by any .gitignore found while walking the source directory.
+	ExcludeFromGitignore bool
+}
+
+// archiverBuilders maps the archive_file "type" attribute value to a
+// constructor for the corresponding Archiver implementation.
+var archiverBuilders = map[string]func(string) Archiver{
+	"zip":     NewZipArchiver,
+	"tar":     NewTarArchiver,
+	"tar.gz":  NewTarGzArchiver,
+	"tar.bz2": NewTarBz2Archiver,
+	"tar.xz":  NewTarXzArchiver,
+}
+
+// NewArchiver returns the Archiver implementation registered for
+// archiveType, writing to filepath. It returns an error if archiveType is
+// not one of the supported, registered formats.
+func NewArchiver(archiveType, filepath string) (Archiver, error) {
+	builder, ok := archiverBuilders[archiveType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported archive type: %s", archiveType)
+	}
+	return builder(filepath), nil
+}
+
+func assertValidFile(infilename string) (os.FileInfo, error) {
+	fi, err := os.Stat(infilename)
+	if err != nil {
+		return nil, fmt.Errorf("could not archive missing file: %s", infilename)
+	}
+	return fi, nil
+}
+
+// resolveSymlinkEntry follows the symlink at path unless skip is true,
+// reporting what a directory walk should do with it: archive it as
+// resolvedInfo, or, when it resolves to a directory, recurse into realPath
+// instead. Both createWalkFunc (which writes entries) and dirSize (which
+// only totals their size) dereference symlinks this same way, so they share
+// this logic rather than risk the two falling out of sync.
+func resolveSymlinkEntry(path string, info os.FileInfo, skip bool) (resolvedInfo os.FileInfo, realPath string, err error) {
+	if info.Mode()&os.ModeSymlink != os.ModeSymlink || skip {
+		return info, "", nil
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	realInfo, err := os.Stat(real)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if realInfo.IsDir() {
+		return nil, real, nil
+	}
+
+	return realInfo, "", nil
+}
+
+func assertValidDir(indirname string) (os.FileInfo, error) {
+	fi, err := os.Stat(indirname)
+	if err != nil {
+		return nil, fmt.Errorf("could not archive missing directory: %s", indirname)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("could not archive directory that is a file: %s", indirname)
+	}
+	return fi, nil
+}
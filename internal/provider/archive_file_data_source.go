@@ -0,0 +1,478 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	stdpath "path"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// supportedArchiveTypes lists the valid values for archive_file's "type"
+// attribute, derived from the registered archiverBuilders.
+func supportedArchiveTypes() []string {
+	types := make([]string, 0, len(archiverBuilders))
+	for t := range archiverBuilders {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// archiveFileDataSource implements the archive_file data source, which
+// packages one or more sources (a single file, a directory, inline
+// content, or a set of content/filename pairs) into an archive of the
+// requested type.
+type archiveFileDataSource struct{}
+
+var _ datasource.DataSource = (*archiveFileDataSource)(nil)
+
+// NewArchiveFileDataSource returns a fresh instance of the archive_file
+// data source.
+func NewArchiveFileDataSource() datasource.DataSource {
+	return &archiveFileDataSource{}
+}
+
+func (d *archiveFileDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_file"
+}
+
+type archiveFileSourceModel struct {
+	Content  types.String `tfsdk:"content"`
+	Filename types.String `tfsdk:"filename"`
+}
+
+type archiveRemoteSourceModel struct {
+	URL      types.String            `tfsdk:"url"`
+	Filename types.String            `tfsdk:"filename"`
+	SHA256   types.String            `tfsdk:"sha256"`
+	Headers  map[string]types.String `tfsdk:"headers"`
+}
+
+type archiveFileDataSourceModel struct {
+	ID                    types.String               `tfsdk:"id"`
+	Type                  types.String               `tfsdk:"type"`
+	SourceContent         types.String               `tfsdk:"source_content"`
+	SourceContentFilename types.String               `tfsdk:"source_content_filename"`
+	SourceFile            types.String               `tfsdk:"source_file"`
+	SourceDir             types.String               `tfsdk:"source_dir"`
+	Source                []archiveFileSourceModel   `tfsdk:"source"`
+	SourceURL             types.String               `tfsdk:"source_url"`
+	SourceURLFilename     types.String               `tfsdk:"source_url_filename"`
+	SourceURLSHA256       types.String               `tfsdk:"source_url_sha256"`
+	SourceURLHeaders      map[string]types.String    `tfsdk:"source_url_headers"`
+	RemoteSource          []archiveRemoteSourceModel `tfsdk:"remote_source"`
+	Excludes              []types.String             `tfsdk:"excludes"`
+	ExcludePatterns       []types.String             `tfsdk:"exclude_patterns"`
+	ExcludeFromGitignore  types.Bool                 `tfsdk:"exclude_from_gitignore"`
+	ExcludeSymlinkDirs    types.Bool                 `tfsdk:"exclude_symlink_directories"`
+	OutputPath            types.String               `tfsdk:"output_path"`
+	OutputFileMode        types.String               `tfsdk:"output_file_mode"`
+	PreserveMetadata      types.Bool                 `tfsdk:"preserve_metadata"`
+	SourceMtime           types.String               `tfsdk:"source_mtime"`
+	MaxOpenFiles          types.Int64                `tfsdk:"max_open_files"`
+	Canonical             types.Bool                 `tfsdk:"canonical"`
+	OutputSize            types.Int64                `tfsdk:"output_size"`
+	OutputSHA             types.String               `tfsdk:"output_sha"`
+	OutputBase64SHA256    types.String               `tfsdk:"output_base64sha256"`
+	OutputMD5             types.String               `tfsdk:"output_md5"`
+	OutputBase64SHA512    types.String               `tfsdk:"output_base64sha512"`
+	OutputSHA512          types.String               `tfsdk:"output_sha512"`
+	OutputCanonicalHash   types.String               `tfsdk:"output_canonical_hash"`
+}
+
+func (d *archiveFileDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Generates an archive file from a source file, directory, or inline content.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+			},
+			"type": schema.StringAttribute{
+				Description: "The type of archive to generate. " +
+					"One of: \"zip\", \"tar\", \"tar.gz\", \"tar.bz2\", \"tar.xz\".",
+				Required: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(supportedArchiveTypes()...),
+				},
+			},
+			"source_content": schema.StringAttribute{
+				Optional: true,
+			},
+			"source_content_filename": schema.StringAttribute{
+				Optional: true,
+			},
+			"source_file": schema.StringAttribute{
+				Optional: true,
+			},
+			"source_dir": schema.StringAttribute{
+				Optional: true,
+			},
+			"source_url": schema.StringAttribute{
+				Description: "HTTP(S) URL to fetch and archive as a single entry, as an alternative to " +
+					"source_content, source_file, source_dir, or source.",
+				Optional: true,
+			},
+			"source_url_filename": schema.StringAttribute{
+				Description: "Archive entry name for source_url. Defaults to the URL's path basename.",
+				Optional:    true,
+			},
+			"source_url_sha256": schema.StringAttribute{
+				Description: "Expected sha256 of the source_url response body, verified before archiving.",
+				Optional:    true,
+			},
+			"source_url_headers": schema.MapAttribute{
+				Description: "HTTP headers to send with the source_url request, e.g. an \"Authorization\" " +
+					"bearer or basic auth header.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"excludes": schema.ListAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"exclude_patterns": schema.ListAttribute{
+				Description: "Doublestar glob patterns (e.g. \"**/*.tfstate\", \"node_modules/**\") matched " +
+					"against each entry's path relative to source_dir.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"exclude_from_gitignore": schema.BoolAttribute{
+				Description: "Also exclude paths matched by any .gitignore found while walking source_dir.",
+				Optional:    true,
+			},
+			"exclude_symlink_directories": schema.BoolAttribute{
+				Description: "Do not recurse into a symlinked directory in source_dir. Unless preserve_metadata " +
+					"(symlinks are then written as symlink entries) or canonical (symlinks are then left out " +
+					"of the archive) is set, archiving a source_dir that contains one fails with an error.",
+				Optional: true,
+			},
+			"output_path": schema.StringAttribute{
+				Required: true,
+			},
+			"output_file_mode": schema.StringAttribute{
+				Description: "String that specifies the octal file mode for all archived files, e.g. \"0666\".",
+				Optional:    true,
+			},
+			"preserve_metadata": schema.BoolAttribute{
+				Description: "Preserve each source file's mode and modification time in the archive, " +
+					"and write symlinks as symlink entries instead of following them. " +
+					"Only supported for the \"zip\" type.",
+				Optional: true,
+			},
+			"source_mtime": schema.StringAttribute{
+				Description: "RFC3339 timestamp to record as every entry's modification time, " +
+					"overriding preserve_metadata's per-file mtime for reproducible builds. " +
+					"Only supported for the \"zip\" type.",
+				Optional: true,
+			},
+			"max_open_files": schema.Int64Attribute{
+				Description: "Bound the number of source_dir files open at once while archiving, " +
+					"to avoid file descriptor exhaustion on very wide trees. " +
+					"Only supported for the \"zip\" type.",
+				Optional: true,
+			},
+			"canonical": schema.BoolAttribute{
+				Description: "Sort source_dir entries by NFC-normalized path and write them under that path " +
+					"with a fixed header and zeroed mtime, so the archive is byte-identical across machines " +
+					"regardless of filesystem ordering or unicode normalization quirks. Entry names keep " +
+					"their case; case differences are not normalized. " +
+					"Only supported for the \"zip\" type.",
+				Optional: true,
+			},
+			"output_size": schema.Int64Attribute{
+				Computed: true,
+			},
+			"output_sha": schema.StringAttribute{
+				Computed: true,
+			},
+			"output_base64sha256": schema.StringAttribute{
+				Computed: true,
+			},
+			"output_md5": schema.StringAttribute{
+				Computed: true,
+			},
+			"output_base64sha512": schema.StringAttribute{
+				Computed: true,
+			},
+			"output_sha512": schema.StringAttribute{
+				Computed: true,
+			},
+			"output_canonical_hash": schema.StringAttribute{
+				Description: "A content-addressed \"h1:\" digest of the archive's entries, set only when " +
+					"canonical is true.",
+				Computed: true,
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"source": schema.SetNestedBlock{
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"content": schema.StringAttribute{
+							Required: true,
+						},
+						"filename": schema.StringAttribute{
+							Required: true,
+						},
+					},
+				},
+			},
+			"remote_source": schema.SetNestedBlock{
+				Description: "One or more HTTP(S) URLs to fetch and archive together, as an alternative to " +
+					"source_url for bundling more than one remote artifact.",
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"url": schema.StringAttribute{
+							Required: true,
+						},
+						"filename": schema.StringAttribute{
+							Required: true,
+						},
+						"sha256": schema.StringAttribute{
+							Optional: true,
+						},
+						"headers": schema.MapAttribute{
+							ElementType: types.StringType,
+							Optional:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *archiveFileDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data archiveFileDataSourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	archiveType := data.Type.ValueString()
+	outputPath := data.OutputPath.ValueString()
+
+	warnZipOnlyAttributes(archiveType, data, &resp.Diagnostics)
+
+	archiver, err := NewArchiver(archiveType, outputPath)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("type"),
+			"Unsupported archive type",
+			err.Error(),
+		)
+		return
+	}
+
+	if mode := data.OutputFileMode.ValueString(); mode != "" {
+		archiver.SetOutputFileMode(mode)
+	}
+
+	if zipArchiver, ok := archiver.(*ZipArchiver); ok {
+		zipArchiver.SetPreserveMetadata(data.PreserveMetadata.ValueBool())
+		if mtime := data.SourceMtime.ValueString(); mtime != "" {
+			if err := zipArchiver.SetSourceMtime(mtime); err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("source_mtime"), "Invalid source_mtime", err.Error())
+				return
+			}
+		}
+		if !data.MaxOpenFiles.IsNull() {
+			zipArchiver.SetMaxOpenFiles(int(data.MaxOpenFiles.ValueInt64()))
+		}
+		zipArchiver.SetCanonicalMode(data.Canonical.ValueBool())
+		zipArchiver.SetProgressFunc(func(bytesWritten, bytesTotal int64, currentPath string) {
+			tflog.Debug(ctx, "archiving source_dir", map[string]interface{}{
+				"bytes_written": bytesWritten,
+				"bytes_total":   bytesTotal,
+				"path":          currentPath,
+			})
+		})
+	}
+
+	if err := d.archive(archiver, data); err != nil {
+		resp.Diagnostics.AddError("Error creating archive", err.Error())
+		return
+	}
+
+	fi, err := os.Stat(outputPath)
+	if err != nil {
+		resp.Diagnostics.AddError("Error reading archive", err.Error())
+		return
+	}
+
+	sums, err := fileSums(outputPath)
+	if err != nil {
+		resp.Diagnostics.AddError("Error computing archive checksums", err.Error())
+		return
+	}
+
+	data.ID = types.StringValue(sums.sha1)
+	data.OutputSize = types.Int64Value(fi.Size())
+	data.OutputSHA = types.StringValue(sums.sha1)
+	data.OutputBase64SHA256 = types.StringValue(sums.base64sha256)
+	data.OutputMD5 = types.StringValue(sums.md5)
+	data.OutputBase64SHA512 = types.StringValue(sums.base64sha512)
+	data.OutputSHA512 = types.StringValue(sums.sha512)
+
+	data.OutputCanonicalHash = types.StringValue("")
+	if zipArchiver, ok := archiver.(*ZipArchiver); ok && data.Canonical.ValueBool() {
+		canonicalHash, err := zipArchiver.ComputeArchiveHash("sha256")
+		if err != nil {
+			resp.Diagnostics.AddError("Error computing canonical archive hash", err.Error())
+			return
+		}
+		data.OutputCanonicalHash = types.StringValue(canonicalHash)
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// warnZipOnlyAttributes adds a warning diagnostic for each of
+// preserve_metadata, source_mtime, max_open_files, and canonical that's set
+// while archiveType isn't "zip", since those attributes are silently
+// ignored by every other archiver implementation.
+func warnZipOnlyAttributes(archiveType string, data archiveFileDataSourceModel, diags *diag.Diagnostics) {
+	if archiveType == "zip" {
+		return
+	}
+
+	const summary = "Attribute ignored for this archive type"
+	if data.PreserveMetadata.ValueBool() {
+		diags.AddAttributeWarning(path.Root("preserve_metadata"), summary,
+			"preserve_metadata is only supported for the \"zip\" type and is ignored here.")
+	}
+	if data.SourceMtime.ValueString() != "" {
+		diags.AddAttributeWarning(path.Root("source_mtime"), summary,
+			"source_mtime is only supported for the \"zip\" type and is ignored here.")
+	}
+	if !data.MaxOpenFiles.IsNull() {
+		diags.AddAttributeWarning(path.Root("max_open_files"), summary,
+			"max_open_files is only supported for the \"zip\" type and is ignored here.")
+	}
+	if data.Canonical.ValueBool() {
+		diags.AddAttributeWarning(path.Root("canonical"), summary,
+			"canonical is only supported for the \"zip\" type and is ignored here.")
+	}
+}
+
+// archive dispatches to the appropriate Archiver method based on which
+// mutually exclusive source attribute was configured.
+func (d *archiveFileDataSource) archive(archiver Archiver, data archiveFileDataSourceModel) error {
+	switch {
+	case data.SourceContent.ValueString() != "":
+		return archiver.ArchiveContent([]byte(data.SourceContent.ValueString()), data.SourceContentFilename.ValueString())
+	case data.SourceFile.ValueString() != "":
+		return archiver.ArchiveFile(data.SourceFile.ValueString())
+	case data.SourceDir.ValueString() != "":
+		excludes := make([]string, len(data.Excludes))
+		for i, e := range data.Excludes {
+			excludes[i] = e.ValueString()
+		}
+		excludePatterns := make([]string, len(data.ExcludePatterns))
+		for i, p := range data.ExcludePatterns {
+			excludePatterns[i] = p.ValueString()
+		}
+		return archiver.ArchiveDir(data.SourceDir.ValueString(), ArchiveDirOpts{
+			Excludes:                  excludes,
+			ExcludePatterns:           excludePatterns,
+			ExcludeFromGitignore:      data.ExcludeFromGitignore.ValueBool(),
+			ExcludeSymlinkDirectories: data.ExcludeSymlinkDirs.ValueBool(),
+		})
+	case len(data.Source) > 0:
+		content := make(map[string][]byte, len(data.Source))
+		for _, s := range data.Source {
+			content[s.Filename.ValueString()] = []byte(s.Content.ValueString())
+		}
+		return archiver.ArchiveMultiple(content)
+	case data.SourceURL.ValueString() != "":
+		filename := data.SourceURLFilename.ValueString()
+		if filename == "" {
+			if u, err := url.Parse(data.SourceURL.ValueString()); err == nil {
+				filename = stdpath.Base(u.Path)
+			}
+		}
+		headers := make(map[string]string, len(data.SourceURLHeaders))
+		for k, v := range data.SourceURLHeaders {
+			headers[k] = v.ValueString()
+		}
+		return archiver.ArchiveRemote(data.SourceURL.ValueString(), filename, RemoteOpts{
+			Headers:        headers,
+			ExpectedSHA256: data.SourceURLSHA256.ValueString(),
+		})
+	case len(data.RemoteSource) > 0:
+		sources := make([]RemoteSource, len(data.RemoteSource))
+		for i, s := range data.RemoteSource {
+			headers := make(map[string]string, len(s.Headers))
+			for k, v := range s.Headers {
+				headers[k] = v.ValueString()
+			}
+			sources[i] = RemoteSource{
+				URL:      s.URL.ValueString(),
+				Filename: s.Filename.ValueString(),
+				Opts: RemoteOpts{
+					Headers:        headers,
+					ExpectedSHA256: s.SHA256.ValueString(),
+				},
+			}
+		}
+		return archiver.ArchiveMultipleRemote(sources)
+	default:
+		return fmt.Errorf("one of source_content, source_file, source_dir, source, source_url, or remote_source must be set")
+	}
+}
+
+type archiveSums struct {
+	sha1         string
+	base64sha256 string
+	md5          string
+	base64sha512 string
+	sha512       string
+}
+
+// fileSums streams path through every digest algorithm at once, rather
+// than reading the whole (potentially very large) archive into memory.
+func fileSums(path string) (archiveSums, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return archiveSums{}, err
+	}
+	defer f.Close()
+
+	sha1Hash := sha1.New()
+	sha256Hash := sha256.New()
+	md5Hash := md5.New()
+	sha512Hash := sha512.New()
+
+	w := io.MultiWriter(sha1Hash, sha256Hash, md5Hash, sha512Hash)
+	if _, err := io.Copy(w, f); err != nil {
+		return archiveSums{}, err
+	}
+
+	return archiveSums{
+		sha1:         hex.EncodeToString(sha1Hash.Sum(nil)),
+		base64sha256: base64.StdEncoding.EncodeToString(sha256Hash.Sum(nil)),
+		md5:          hex.EncodeToString(md5Hash.Sum(nil)),
+		base64sha512: base64.StdEncoding.EncodeToString(sha512Hash.Sum(nil)),
+		sha512:       hex.EncodeToString(sha512Hash.Sum(nil)),
+	}, nil
+}
@@ -0,0 +1,38 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestWarnZipOnlyAttributes(t *testing.T) {
+	zipOnly := archiveFileDataSourceModel{
+		PreserveMetadata: types.BoolValue(true),
+		SourceMtime:      types.StringValue("2024-01-01T00:00:00Z"),
+		MaxOpenFiles:     types.Int64Value(10),
+		Canonical:        types.BoolValue(true),
+	}
+
+	var diags diag.Diagnostics
+	warnZipOnlyAttributes("zip", zipOnly, &diags)
+	if diags.HasError() || len(diags) != 0 {
+		t.Errorf("expected no diagnostics for type \"zip\", got: %v", diags)
+	}
+
+	diags = nil
+	warnZipOnlyAttributes("tar", zipOnly, &diags)
+	if len(diags) != 4 {
+		t.Errorf("expected 4 warnings for zip-only attributes set with type \"tar\", got %d: %v", len(diags), diags)
+	}
+
+	diags = nil
+	warnZipOnlyAttributes("tar", archiveFileDataSourceModel{}, &diags)
+	if len(diags) != 0 {
+		t.Errorf("expected no warnings when no zip-only attribute is set, got: %v", diags)
+	}
+}
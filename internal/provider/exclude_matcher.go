@@ -0,0 +1,171 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// gitignoreMatcher evaluates the .gitignore files discovered while walking
+// a source directory. Rules are kept in walk order (shallowest directory
+// first) as one combined, ordered pattern list, so precedence follows git's
+// own: the last pattern matching a given path wins, regardless of which
+// .gitignore it came from, letting a deeper, more-specific .gitignore's "!"
+// re-include a path a shallower one excluded.
+type gitignoreMatcher struct {
+	rules []gitignoreRule
+}
+
+type gitignoreRule struct {
+	dir   string // archive-relative directory the .gitignore lives in, "" for the root
+	lines []string
+}
+
+// loadGitignoreMatcher walks indirname collecting every .gitignore file
+// into a gitignoreMatcher scoped to the directory it was found in.
+func loadGitignoreMatcher(indirname string) (*gitignoreMatcher, error) {
+	m := &gitignoreMatcher{}
+
+	err := filepath.Walk(indirname, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Base(path) != ".gitignore" {
+			return nil
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %s", path, err)
+		}
+
+		rel, err := filepath.Rel(indirname, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			rel = ""
+		}
+
+		var lines []string
+		for _, line := range strings.Split(string(raw), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			lines = append(lines, line)
+		}
+
+		m.rules = append(m.rules, gitignoreRule{
+			dir:   filepath.ToSlash(rel),
+			lines: lines,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// matches reports whether slashPath (archive-relative, forward-slash),
+// which is a directory when isDir is true, is excluded. Every collected
+// .gitignore line is evaluated as one ordered list (shallowest directory's
+// lines first): the last line whose pattern matches slashPath decides the
+// verdict, so a more specific, deeper .gitignore's pattern -- including a
+// "!" re-include -- overrides an earlier one's.
+func (m *gitignoreMatcher) matches(slashPath string, isDir bool) bool {
+	excluded := false
+	for _, r := range m.rules {
+		rel := slashPath
+		if r.dir != "" {
+			prefix := r.dir + "/"
+			if !strings.HasPrefix(slashPath, prefix) {
+				continue
+			}
+			rel = strings.TrimPrefix(slashPath, prefix)
+		}
+		for _, line := range r.lines {
+			if matched, negate := matchGitignoreLine(line, rel, isDir); matched {
+				excluded = !negate
+			}
+		}
+	}
+	return excluded
+}
+
+// matchGitignoreLine reports whether a single .gitignore pattern line
+// matches relPath (relative to the directory the line's file lives in, and
+// a directory itself when isDir is true), and whether the line is a "!"
+// negation. A leading "\!" or "\#" escapes what would otherwise be parsed
+// as negation or a comment marker, letting a pattern match a literal "!" or
+// "#". A pattern without a "/" (other than a trailing one) matches at any
+// depth, matching git's own semantics. A trailing "/" restricts the
+// pattern to directories only, as git specifies.
+func matchGitignoreLine(line, relPath string, isDir bool) (matched bool, negate bool) {
+	p := line
+	if strings.HasPrefix(p, "\\!") || strings.HasPrefix(p, "\\#") {
+		p = p[1:]
+	} else if strings.HasPrefix(p, "!") {
+		negate = true
+		p = p[1:]
+	}
+
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+	if dirOnly && !isDir {
+		return false, negate
+	}
+
+	anchored := strings.HasPrefix(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return false, negate
+	}
+
+	glob := p
+	if !anchored && !strings.Contains(p, "/") {
+		glob = "**/" + p
+	}
+
+	matched, _ = doublestar.Match(glob, relPath)
+	return matched, negate
+}
+
+// matchExcludes reports whether archivePath, a directory when isDir is
+// true, should be left out of the archive under opts: an exact
+// opts.Excludes match, an opts.ExcludePatterns doublestar glob match, or
+// (when gi is non-nil) a .gitignore match.
+func matchExcludes(archivePath string, isDir bool, opts ArchiveDirOpts, gi *gitignoreMatcher) (bool, error) {
+	if checkMatch(archivePath, opts.Excludes) {
+		return true, nil
+	}
+
+	slashPath := filepath.ToSlash(archivePath)
+
+	for _, pattern := range opts.ExcludePatterns {
+		if pattern == "" {
+			continue
+		}
+		matched, err := doublestar.Match(pattern, slashPath)
+		if err != nil {
+			return false, fmt.Errorf("invalid exclude pattern %q: %s", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+
+	if gi != nil && gi.matches(slashPath, isDir) {
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignoreMatcher_NestedNegationOverridesParent(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", ".gitignore"), []byte("!important.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	gi, err := loadGitignoreMatcher(dir)
+	if err != nil {
+		t.Fatalf("loadGitignoreMatcher() error = %v", err)
+	}
+
+	tests := map[string]bool{
+		"root.log":          true,
+		"sub/important.log": false, // re-included by the more specific, deeper .gitignore
+		"sub/other.log":     true,  // still excluded by the root .gitignore
+		"sub/important.txt": false,
+	}
+	for path, wantExcluded := range tests {
+		if got := gi.matches(path, false); got != wantExcluded {
+			t.Errorf("matches(%q) = %v, want %v", path, got, wantExcluded)
+		}
+	}
+}
+
+func TestMatchGitignoreLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		relPath string
+		isDir   bool
+		matched bool
+		negate  bool
+	}{
+		{name: "char class", line: "*.[oa]", relPath: "main.o", matched: true},
+		{name: "char class no match", line: "*.[oa]", relPath: "main.c", matched: false},
+		{name: "escaped bang is literal", line: `\!important.txt`, relPath: "!important.txt", matched: true},
+		{name: "escaped hash is literal", line: `\#comment.txt`, relPath: "#comment.txt", matched: true},
+		{name: "negation still recognized unescaped", line: "!keep.txt", relPath: "keep.txt", matched: true, negate: true},
+		{name: "dir-only pattern matches directory", line: "build/", relPath: "build", isDir: true, matched: true},
+		{name: "dir-only pattern does not match file", line: "build/", relPath: "build", isDir: false, matched: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, negate := matchGitignoreLine(tt.line, tt.relPath, tt.isDir)
+			if matched != tt.matched || negate != tt.negate {
+				t.Errorf("matchGitignoreLine(%q, %q, %v) = (%v, %v), want (%v, %v)", tt.line, tt.relPath, tt.isDir, matched, negate, tt.matched, tt.negate)
+			}
+		})
+	}
+}
+
+func TestTarArchiver_ArchiveDir_ExcludeDirOnlyGitignorePattern(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, ".gitignore"), []byte("build/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(srcDir, "build"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "build", "out.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "keep.txt"), []byte("y"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarPath := filepath.Join(dir, "out.tar")
+	a := NewTarArchiver(tarPath)
+	if err := a.ArchiveDir(srcDir, ArchiveDirOpts{ExcludeFromGitignore: true}); err != nil {
+		t.Fatalf("ArchiveDir() error = %v", err)
+	}
+
+	for _, name := range readTarNames(t, tarPath) {
+		if name == "build/out.txt" || name == "build" {
+			t.Errorf("expected the build/ directory to be excluded, got entry %q", name)
+		}
+	}
+}
+
+func TestTarArchiver_ArchiveDir_ExcludePatterns(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "secret.tfstate"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "keep.txt"), []byte("y"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarPath := filepath.Join(dir, "out.tar")
+	a := NewTarArchiver(tarPath)
+	if err := a.ArchiveDir(srcDir, ArchiveDirOpts{ExcludePatterns: []string{"*.tfstate"}}); err != nil {
+		t.Fatalf("ArchiveDir() error = %v", err)
+	}
+
+	names := readTarNames(t, tarPath)
+	if len(names) != 1 || names[0] != "keep.txt" {
+		t.Errorf("expected only keep.txt, got: %v", names)
+	}
+}
+
+func TestTarArchiver_ArchiveDir_ExcludeFromGitignore(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, ".gitignore"), []byte("*.tfstate\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "secret.tfstate"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "keep.txt"), []byte("y"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarPath := filepath.Join(dir, "out.tar")
+	a := NewTarArchiver(tarPath)
+	if err := a.ArchiveDir(srcDir, ArchiveDirOpts{ExcludeFromGitignore: true}); err != nil {
+		t.Fatalf("ArchiveDir() error = %v", err)
+	}
+
+	for _, name := range readTarNames(t, tarPath) {
+		if name == "secret.tfstate" {
+			t.Error("expected secret.tfstate to be excluded via .gitignore")
+		}
+	}
+}
+
+// readTarNames returns the entry names of the tar file at tarPath.
+func readTarNames(t *testing.T, tarPath string) []string {
+	t.Helper()
+	f, err := os.Open(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		th, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, th.Name)
+	}
+	return names
+}
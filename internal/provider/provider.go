@@ -0,0 +1,45 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+)
+
+// archiveProvider implements the archive provider. It has no configuration
+// of its own; it only exposes the archive_file data source.
+type archiveProvider struct{}
+
+var _ provider.Provider = (*archiveProvider)(nil)
+
+// New returns a fresh instance of the archive provider.
+func New() provider.Provider {
+	return &archiveProvider{}
+}
+
+func (p *archiveProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "archive"
+}
+
+func (p *archiveProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
+	resp.Schema = schema.Schema{}
+}
+
+func (p *archiveProvider) Configure(_ context.Context, _ provider.ConfigureRequest, _ *provider.ConfigureResponse) {
+}
+
+func (p *archiveProvider) Resources(_ context.Context) []func() resource.Resource {
+	return nil
+}
+
+func (p *archiveProvider) DataSources(_ context.Context) []func() datasource.DataSource {
+	return []func() datasource.DataSource{
+		NewArchiveFileDataSource,
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// RemoteOpts customizes Archiver.ArchiveRemote's HTTP fetch.
+type RemoteOpts struct {
+	// Client is used to perform the request. If nil, a client with a
+	// 30-second timeout and the environment's proxy settings is used.
+	Client *http.Client
+
+	// Headers are set on the request, e.g. for a bearer or basic auth
+	// "Authorization" header.
+	Headers map[string]string
+
+	// ExpectedSHA256, if set, is verified against the fetched content's
+	// sha256 once the fetch completes.
+	ExpectedSHA256 string
+}
+
+// fetchRemoteResponse issues the HTTP(S) GET described by opts and returns
+// the response after validating its status code. The caller is responsible
+// for reading and closing resp.Body.
+func fetchRemoteResponse(url string, opts RemoteOpts) (*http.Response, error) {
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for %s: %s", url, err)
+	}
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %s", url, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error fetching %s: unexpected status %s", url, resp.Status)
+	}
+
+	return resp, nil
+}
+
+// verifyRemoteSHA256 returns an error if opts.ExpectedSHA256 is set and
+// doesn't match sum, the sha256 digest of what was actually fetched from
+// url.
+func verifyRemoteSHA256(url string, opts RemoteOpts, sum []byte) error {
+	if opts.ExpectedSHA256 == "" {
+		return nil
+	}
+	if got := hex.EncodeToString(sum); got != opts.ExpectedSHA256 {
+		return fmt.Errorf("sha256 mismatch for %s: expected %s, got %s", url, opts.ExpectedSHA256, got)
+	}
+	return nil
+}
+
+// removeOnError deletes the archive at path if err is non-nil, so a fetch
+// that fails partway through -- including an ExpectedSHA256 mismatch caught
+// only after the bytes were already streamed into the archive's output file
+// -- never leaves behind a finalized file that looks like a validated
+// artifact. It returns err unchanged when the file is removed or was never
+// created; the caller should return its result directly. A failure to remove
+// the file is folded into the returned error rather than swallowed, since a
+// leftover invalid artifact is exactly what this guards against.
+func removeOnError(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if rmErr := os.Remove(path); rmErr != nil && !os.IsNotExist(rmErr) {
+		return fmt.Errorf("%s (additionally, failed to remove invalid output file: %s)", err, rmErr)
+	}
+	return err
+}
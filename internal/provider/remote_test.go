@@ -0,0 +1,86 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveRemote_StreamsIntoZipAndTar(t *testing.T) {
+	body := []byte("remote artifact content")
+	sum := sha256.Sum256(body)
+	hexSum := hex.EncodeToString(sum[:])
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+
+	zipPath := filepath.Join(dir, "out.zip")
+	if err := NewZipArchiver(zipPath).ArchiveRemote(srv.URL, "remote.txt", RemoteOpts{ExpectedSHA256: hexSum}); err != nil {
+		t.Fatalf("zip ArchiveRemote() error = %v", err)
+	}
+	if got := readZipEntry(t, zipPath, "remote.txt"); got != string(body) {
+		t.Errorf("zip entry content = %q, want %q", got, body)
+	}
+
+	tarPath := filepath.Join(dir, "out.tar")
+	if err := NewTarArchiver(tarPath).ArchiveRemote(srv.URL, "remote.txt", RemoteOpts{ExpectedSHA256: hexSum}); err != nil {
+		t.Fatalf("tar ArchiveRemote() error = %v", err)
+	}
+
+	f, err := os.Open(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	th, err := tr.Next()
+	if err != nil {
+		t.Fatalf("reading tar header: %v", err)
+	}
+	got, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(body) {
+		t.Errorf("tar entry %q content = %q, want %q", th.Name, got, body)
+	}
+}
+
+func TestArchiveRemote_SHA256MismatchErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("unexpected content"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "out.zip")
+	err := NewZipArchiver(zipPath).ArchiveRemote(srv.URL, "remote.txt", RemoteOpts{ExpectedSHA256: "0000000000000000000000000000000000000000000000000000000000000000"})
+	if err == nil {
+		t.Fatal("expected a sha256 mismatch error, got nil")
+	}
+	if _, statErr := os.Stat(zipPath); !os.IsNotExist(statErr) {
+		t.Errorf("expected %s to be removed after a sha256 mismatch, stat error = %v", zipPath, statErr)
+	}
+
+	tarPath := filepath.Join(dir, "out.tar")
+	err = NewTarArchiver(tarPath).ArchiveRemote(srv.URL, "remote.txt", RemoteOpts{ExpectedSHA256: "0000000000000000000000000000000000000000000000000000000000000000"})
+	if err == nil {
+		t.Fatal("expected a sha256 mismatch error, got nil")
+	}
+	if _, statErr := os.Stat(tarPath); !os.IsNotExist(statErr) {
+		t.Errorf("expected %s to be removed after a sha256 mismatch, stat error = %v", tarPath, statErr)
+	}
+}
@@ -0,0 +1,407 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/ulikunitz/xz"
+)
+
+// tarCompressor wraps the raw tar byte stream in a streaming compression
+// codec. It is nil for a plain, uncompressed tar archive.
+type tarCompressor func(w io.Writer) (io.WriteCloser, error)
+
+// TarArchiver writes archive entries as a tar stream, optionally piped
+// through a compressor. NewTarArchiver, NewTarGzArchiver, NewTarBz2Archiver,
+// and NewTarXzArchiver each configure it for one of the supported formats.
+type TarArchiver struct {
+	filepath       string
+	outputFileMode string // Default value "" means unset
+	compressor     tarCompressor
+	filewriter     *os.File
+	compressWriter io.WriteCloser
+	writer         *tar.Writer
+}
+
+func newTarArchiver(filepath string, compressor tarCompressor) Archiver {
+	return &TarArchiver{
+		filepath:   filepath,
+		compressor: compressor,
+	}
+}
+
+// NewTarArchiver returns an Archiver that writes an uncompressed tar file.
+func NewTarArchiver(filepath string) Archiver {
+	return newTarArchiver(filepath, nil)
+}
+
+// NewTarGzArchiver returns an Archiver that writes a gzip-compressed tar
+// file.
+func NewTarGzArchiver(filepath string) Archiver {
+	return newTarArchiver(filepath, func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	})
+}
+
+// NewTarBz2Archiver returns an Archiver that writes a bzip2-compressed tar
+// file.
+func NewTarBz2Archiver(filepath string) Archiver {
+	return newTarArchiver(filepath, func(w io.Writer) (io.WriteCloser, error) {
+		return bzip2.NewWriter(w, nil)
+	})
+}
+
+// NewTarXzArchiver returns an Archiver that writes an xz-compressed tar
+// file.
+func NewTarXzArchiver(filepath string) Archiver {
+	return newTarArchiver(filepath, func(w io.Writer) (io.WriteCloser, error) {
+		return xz.NewWriter(w)
+	})
+}
+
+func (a *TarArchiver) ArchiveContent(content []byte, infilename string) error {
+	mode, err := a.fileMode(0o644)
+	if err != nil {
+		return err
+	}
+
+	if err := a.open(); err != nil {
+		return err
+	}
+	defer a.close()
+
+	return a.writeHeaderAndContent(&tar.Header{
+		Name: filepath.ToSlash(infilename),
+		Mode: mode,
+		Size: int64(len(content)),
+	}, content)
+}
+
+// ArchiveRemote streams url's response body into the archive as filename.
+// opts.ExpectedSHA256 is checked once the entry is written, and the archive
+// is then closed; if the fetch or the check failed, the output file is
+// removed rather than left on disk looking like a validated artifact.
+func (a *TarArchiver) ArchiveRemote(url, filename string, opts RemoteOpts) error {
+	if err := a.open(); err != nil {
+		return err
+	}
+	err := a.writeRemoteEntry(url, filename, opts)
+	a.close()
+	return removeOnError(a.filepath, err)
+}
+
+// ArchiveMultipleRemote fetches each source's URL and streams it into the
+// archive under its Filename, all within one open archive, so bundling
+// several remote artifacts never requires holding more than one of them
+// fully in memory at once. Entries are written in Filename-sorted order, to
+// match ArchiveMultiple's deterministic ordering. Each source's
+// ExpectedSHA256 is checked right after its entry is written, and the first
+// failure stops the loop; the archive is then closed and, if fetching or
+// verifying any source failed, the output file is removed rather than left
+// on disk as a partial or unvalidated artifact.
+func (a *TarArchiver) ArchiveMultipleRemote(sources []RemoteSource) error {
+	sorted := make([]RemoteSource, len(sources))
+	copy(sorted, sources)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Filename < sorted[j].Filename })
+
+	if err := a.open(); err != nil {
+		return err
+	}
+
+	var err error
+	for _, s := range sorted {
+		if err = a.writeRemoteEntry(s.URL, s.Filename, s.Opts); err != nil {
+			break
+		}
+	}
+	a.close()
+	return removeOnError(a.filepath, err)
+}
+
+// writeRemoteEntry fetches url and streams it into the already-open archive
+// as filename, verifying opts.ExpectedSHA256 once the copy completes. A tar
+// entry's header must declare its size before any content is written, so
+// this streams directly when the server advertises a Content-Length and
+// only buffers the body -- which the format, not this code, requires here
+// -- when it doesn't. It assumes a.open has already been called; the caller
+// is responsible for closing the archive and handling any failure.
+func (a *TarArchiver) writeRemoteEntry(url, filename string, opts RemoteOpts) error {
+	resp, err := fetchRemoteResponse(url, opts)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	mode, err := a.fileMode(0o644)
+	if err != nil {
+		return err
+	}
+
+	th := &tar.Header{
+		Name: filepath.ToSlash(filename),
+		Mode: mode,
+	}
+
+	h := sha256.New()
+	if resp.ContentLength >= 0 {
+		th.Size = resp.ContentLength
+		if err := a.writer.WriteHeader(th); err != nil {
+			return fmt.Errorf("error creating file inside archive: %s", err)
+		}
+		if _, err := io.Copy(io.MultiWriter(a.writer, h), resp.Body); err != nil {
+			return fmt.Errorf("error streaming %s into archive: %s", url, err)
+		}
+	} else {
+		content, err := io.ReadAll(io.TeeReader(resp.Body, h))
+		if err != nil {
+			return fmt.Errorf("error reading response body for %s: %s", url, err)
+		}
+		if err := a.writeHeaderAndContent(th, content); err != nil {
+			return err
+		}
+	}
+
+	return verifyRemoteSHA256(url, opts, h.Sum(nil))
+}
+
+func (a *TarArchiver) ArchiveFile(infilename string) error {
+	fi, err := assertValidFile(infilename)
+	if err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(infilename)
+	if err != nil {
+		return err
+	}
+
+	if err := a.open(); err != nil {
+		return err
+	}
+	defer a.close()
+
+	th, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return fmt.Errorf("error creating file header: %s", err)
+	}
+	th.Name = filepath.ToSlash(fi.Name())
+	th.ModTime = time.Time{}
+	zeroHeaderOwnership(th)
+	if a.outputFileMode != "" {
+		mode, err := a.fileMode(th.Mode)
+		if err != nil {
+			return err
+		}
+		th.Mode = mode
+	}
+
+	return a.writeHeaderAndContent(th, content)
+}
+
+func (a *TarArchiver) ArchiveDir(indirname string, opts ArchiveDirOpts) error {
+	_, err := assertValidDir(indirname)
+	if err != nil {
+		return err
+	}
+
+	// ensure exclusions are OS compatible paths
+	for i := range opts.Excludes {
+		opts.Excludes[i] = filepath.FromSlash(opts.Excludes[i])
+	}
+
+	var gi *gitignoreMatcher
+	if opts.ExcludeFromGitignore {
+		gi, err = loadGitignoreMatcher(indirname)
+		if err != nil {
+			return fmt.Errorf("error loading .gitignore files: %s", err)
+		}
+	}
+
+	if err := a.open(); err != nil {
+		return err
+	}
+	defer a.close()
+
+	return filepath.Walk(indirname, a.createWalkFunc("", indirname, opts, gi))
+}
+
+func (a *TarArchiver) createWalkFunc(basePath string, indirname string, opts ArchiveDirOpts, gi *gitignoreMatcher) func(path string, info os.FileInfo, err error) error {
+	return func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error encountered during file walk: %s", err)
+		}
+
+		relname, err := filepath.Rel(indirname, path)
+		if err != nil {
+			return fmt.Errorf("error relativizing file for archival: %s", err)
+		}
+
+		archivePath := filepath.Join(basePath, relname)
+
+		isMatch, err := matchExcludes(archivePath, info.IsDir(), opts, gi)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if isMatch {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if isMatch {
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
+			resolvedInfo, realPath, err := resolveSymlinkEntry(path, info, opts.ExcludeSymlinkDirectories)
+			if err != nil {
+				return err
+			}
+			if realPath != "" {
+				return filepath.Walk(realPath, a.createWalkFunc(archivePath, realPath, opts, gi))
+			}
+			info = resolvedInfo
+		}
+
+		th, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return fmt.Errorf("error creating file header: %s", err)
+		}
+		th.Name = filepath.ToSlash(archivePath)
+		th.ModTime = time.Time{}
+		zeroHeaderOwnership(th)
+		if a.outputFileMode != "" {
+			mode, err := a.fileMode(th.Mode)
+			if err != nil {
+				return err
+			}
+			th.Mode = mode
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading file for archival: %s", err)
+		}
+
+		return a.writeHeaderAndContent(th, content)
+	}
+}
+
+func (a *TarArchiver) ArchiveMultiple(content map[string][]byte) error {
+	if err := a.open(); err != nil {
+		return err
+	}
+	defer a.close()
+
+	// Ensure files are processed in the same order so hashes don't change
+	keys := make([]string, len(content))
+	i := 0
+	for k := range content {
+		keys[i] = k
+		i++
+	}
+	sort.Strings(keys)
+
+	mode, err := a.fileMode(0o644)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range keys {
+		data := content[filename]
+		if err := a.writeHeaderAndContent(&tar.Header{
+			Name: filepath.ToSlash(filename),
+			Mode: mode,
+			Size: int64(len(data)),
+		}, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *TarArchiver) SetOutputFileMode(outputFileMode string) {
+	a.outputFileMode = outputFileMode
+}
+
+func (a *TarArchiver) fileMode(fallback int64) (int64, error) {
+	if a.outputFileMode == "" {
+		return fallback, nil
+	}
+	filemode, err := strconv.ParseUint(a.outputFileMode, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing output_file_mode value: %s", a.outputFileMode)
+	}
+	return int64(filemode), nil
+}
+
+// zeroHeaderOwnership clears the Uid/Gid/Uname/Gname that
+// tar.FileInfoHeader copies from the building machine's os.FileInfo, so
+// the same source tree archived by different users or on different
+// machines produces byte-identical tar entries.
+func zeroHeaderOwnership(th *tar.Header) {
+	th.Uid = 0
+	th.Gid = 0
+	th.Uname = ""
+	th.Gname = ""
+}
+
+func (a *TarArchiver) writeHeaderAndContent(th *tar.Header, content []byte) error {
+	th.Size = int64(len(content))
+	if err := a.writer.WriteHeader(th); err != nil {
+		return fmt.Errorf("error creating file inside archive: %s", err)
+	}
+	_, err := a.writer.Write(content)
+	return err
+}
+
+func (a *TarArchiver) open() error {
+	f, err := os.Create(a.filepath)
+	if err != nil {
+		return err
+	}
+	a.filewriter = f
+
+	w := io.Writer(f)
+	if a.compressor != nil {
+		cw, err := a.compressor(f)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		a.compressWriter = cw
+		w = cw
+	}
+
+	a.writer = tar.NewWriter(w)
+	return nil
+}
+
+func (a *TarArchiver) close() {
+	if a.writer != nil {
+		a.writer.Close()
+		a.writer = nil
+	}
+	if a.compressWriter != nil {
+		a.compressWriter.Close()
+		a.compressWriter = nil
+	}
+	if a.filewriter != nil {
+		a.filewriter.Close()
+		a.filewriter = nil
+	}
+}
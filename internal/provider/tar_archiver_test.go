@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTarArchiver_ArchiveFile_ZeroesOwnership(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "source.txt")
+	if err := os.WriteFile(srcPath, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarPath := filepath.Join(dir, "out.tar")
+	a := NewTarArchiver(tarPath)
+	if err := a.ArchiveFile(srcPath); err != nil {
+		t.Fatalf("ArchiveFile() error = %v", err)
+	}
+
+	th := readSoleTarHeader(t, tarPath)
+	if th.Uid != 0 || th.Gid != 0 || th.Uname != "" || th.Gname != "" {
+		t.Errorf("entry ownership not zeroed: Uid=%d Gid=%d Uname=%q Gname=%q", th.Uid, th.Gid, th.Uname, th.Gname)
+	}
+}
+
+func TestTarArchiver_ArchiveDir_ZeroesOwnership(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarPath := filepath.Join(dir, "out.tar")
+	a := NewTarArchiver(tarPath)
+	if err := a.ArchiveDir(srcDir, ArchiveDirOpts{}); err != nil {
+		t.Fatalf("ArchiveDir() error = %v", err)
+	}
+
+	th := readSoleTarHeader(t, tarPath)
+	if th.Uid != 0 || th.Gid != 0 || th.Uname != "" || th.Gname != "" {
+		t.Errorf("entry ownership not zeroed: Uid=%d Gid=%d Uname=%q Gname=%q", th.Uid, th.Gid, th.Uname, th.Gname)
+	}
+}
+
+func TestTarArchiver_SetOutputFileMode_InvalidValueErrors(t *testing.T) {
+	dir := t.TempDir()
+	a := NewTarArchiver(filepath.Join(dir, "out.tar"))
+	a.SetOutputFileMode("not-an-octal-mode")
+
+	if err := a.ArchiveContent([]byte("content"), "file.txt"); err == nil {
+		t.Fatal("expected an error for an invalid output_file_mode, got nil")
+	}
+}
+
+// readSoleTarHeader opens tarPath and returns the header of its one entry.
+func readSoleTarHeader(t *testing.T, tarPath string) *tar.Header {
+	t.Helper()
+	f, err := os.Open(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	th, err := tr.Next()
+	if err != nil {
+		t.Fatalf("reading tar header: %v", err)
+	}
+	return th
+}
@@ -5,19 +5,46 @@ package archive
 
 import (
 	"archive/zip"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 )
 
+// ProgressFunc reports archiving progress while ArchiveDir streams a
+// source_dir to disk. bytesWritten and bytesTotal are cumulative across the
+// whole walk; currentPath is the archive-relative path most recently
+// completed.
+type ProgressFunc func(bytesWritten, bytesTotal int64, currentPath string)
+
+// copyBufferPool reuses copy buffers across entries so ArchiveDir doesn't
+// allocate a new one per file when streaming a wide tree.
+var copyBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 32*1024)
+		return &buf
+	},
+}
+
 type ZipArchiver struct {
-	filepath       string
-	outputFileMode string // Default value "" means unset
-	filewriter     *os.File
-	writer         *zip.Writer
+	filepath         string
+	outputFileMode   string // Default value "" means unset
+	preserveMetadata bool
+	sourceMtime      *time.Time
+	progressFunc     ProgressFunc
+	maxOpenFiles     int
+	openFilesSem     chan struct{}
+	bytesWritten     int64
+	bytesTotal       int64
+	canonicalMode    bool
+	entryDigests     []entryDigest
+	filewriter       *os.File
+	writer           *zip.Writer
 }
 
 func NewZipArchiver(filepath string) Archiver {
@@ -32,13 +59,17 @@ func (a *ZipArchiver) ArchiveContent(content []byte, infilename string) error {
 	}
 	defer a.close()
 
-	f, err := a.writer.Create(filepath.ToSlash(infilename))
+	name := filepath.ToSlash(infilename)
+	f, err := a.writer.Create(name)
 	if err != nil {
 		return err
 	}
 
-	_, err = f.Write(content)
-	return err
+	if _, err := f.Write(content); err != nil {
+		return err
+	}
+	a.recordDigest(name, content)
+	return nil
 }
 
 func (a *ZipArchiver) ArchiveFile(infilename string) error {
@@ -64,7 +95,10 @@ func (a *ZipArchiver) ArchiveFile(infilename string) error {
 	fh.Name = filepath.ToSlash(fi.Name())
 	fh.Method = zip.Deflate
 	//nolint:staticcheck // This is required as fh.SetModTime has been deprecated since Go 1.10 and using fh.Modified alone isn't enough when using a zero value
-	fh.SetModTime(time.Time{})
+	fh.SetModTime(a.entryModTime(fi))
+	if a.preserveMetadata {
+		fh.SetMode(fi.Mode())
+	}
 
 	if a.outputFileMode != "" {
 		filemode, err := strconv.ParseUint(a.outputFileMode, 0, 32)
@@ -79,8 +113,77 @@ func (a *ZipArchiver) ArchiveFile(infilename string) error {
 		return fmt.Errorf("error creating file inside archive: %s", err)
 	}
 
-	_, err = f.Write(content)
-	return err
+	if _, err := f.Write(content); err != nil {
+		return err
+	}
+	a.recordDigest(fh.Name, content)
+	return nil
+}
+
+// ArchiveRemote streams url's response body directly into the archive as
+// filename, so bundling a remote artifact never requires holding it fully
+// in memory, and verifies opts.ExpectedSHA256 once the copy completes. The
+// archive is then closed; if the fetch or the check failed, the output file
+// is removed rather than left on disk looking like a validated artifact.
+func (a *ZipArchiver) ArchiveRemote(url, filename string, opts RemoteOpts) error {
+	if err := a.open(); err != nil {
+		return err
+	}
+	err := a.writeRemoteEntry(url, filename, opts)
+	a.close()
+	return removeOnError(a.filepath, err)
+}
+
+// ArchiveMultipleRemote fetches each source's URL and streams it into the
+// archive under its Filename, all within one open archive, so bundling
+// several remote artifacts never requires holding more than one of them
+// fully in memory at once. Entries are written in Filename-sorted order, to
+// match ArchiveMultiple's deterministic ordering. Each source's
+// ExpectedSHA256 is checked right after its entry is written, and the first
+// failure stops the loop; the archive is then closed and, if fetching or
+// verifying any source failed, the output file is removed rather than left
+// on disk as a partial or unvalidated artifact.
+func (a *ZipArchiver) ArchiveMultipleRemote(sources []RemoteSource) error {
+	sorted := make([]RemoteSource, len(sources))
+	copy(sorted, sources)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Filename < sorted[j].Filename })
+
+	if err := a.open(); err != nil {
+		return err
+	}
+
+	var err error
+	for _, s := range sorted {
+		if err = a.writeRemoteEntry(s.URL, s.Filename, s.Opts); err != nil {
+			break
+		}
+	}
+	a.close()
+	return removeOnError(a.filepath, err)
+}
+
+// writeRemoteEntry fetches url and streams it into the already-open archive
+// as filename, verifying opts.ExpectedSHA256 once the copy completes. It
+// assumes a.open has already been called; the caller is responsible for
+// closing the archive and handling any failure.
+func (a *ZipArchiver) writeRemoteEntry(url, filename string, opts RemoteOpts) error {
+	resp, err := fetchRemoteResponse(url, opts)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	name := filepath.ToSlash(filename)
+	f, err := a.writer.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.copyWithBufferAndDigest(f, resp.Body, name); err != nil {
+		return fmt.Errorf("error streaming %s into archive: %s", url, err)
+	}
+
+	return verifyRemoteSHA256(url, opts, a.entryDigests[len(a.entryDigests)-1].sha256)
 }
 
 func checkMatch(fileName string, excludes []string) (value bool) {
@@ -107,15 +210,98 @@ func (a *ZipArchiver) ArchiveDir(indirname string, opts ArchiveDirOpts) error {
 		opts.Excludes[i] = filepath.FromSlash(opts.Excludes[i])
 	}
 
+	var gi *gitignoreMatcher
+	if opts.ExcludeFromGitignore {
+		gi, err = loadGitignoreMatcher(indirname)
+		if err != nil {
+			return fmt.Errorf("error loading .gitignore files: %s", err)
+		}
+	}
+
+	if a.canonicalMode {
+		return a.archiveDirCanonical(indirname, opts, gi)
+	}
+
+	total, err := a.dirSize("", indirname, opts, gi)
+	if err != nil {
+		return fmt.Errorf("error pre-walking directory to size archive: %s", err)
+	}
+	a.bytesTotal = total
+	a.bytesWritten = 0
+	if a.maxOpenFiles > 0 {
+		a.openFilesSem = make(chan struct{}, a.maxOpenFiles)
+	}
+
 	if err := a.open(); err != nil {
 		return err
 	}
 	defer a.close()
 
-	return filepath.Walk(indirname, a.createWalkFunc("", indirname, opts))
+	return filepath.Walk(indirname, a.createWalkFunc("", indirname, opts, gi))
 }
 
-func (a *ZipArchiver) createWalkFunc(basePath string, indirname string, opts ArchiveDirOpts) func(path string, info os.FileInfo, err error) error {
+// dirSize pre-walks indirname to total the size of the files ArchiveDir will
+// write, respecting opts' excludes and dereferencing symlinks the same way
+// createWalkFunc does (via resolveSymlinkEntry), so a ProgressFunc can
+// report against a known bytesTotal before any bytes are copied. basePath is
+// the archive-relative path indirname is rooted at, "" at the top call;
+// recursive calls for a symlinked directory pass the symlink's archive path
+// so excludes still match the path the entry will actually be archived
+// under.
+func (a *ZipArchiver) dirSize(basePath, indirname string, opts ArchiveDirOpts, gi *gitignoreMatcher) (int64, error) {
+	var total int64
+	err := filepath.Walk(indirname, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relname, err := filepath.Rel(indirname, path)
+		if err != nil {
+			return err
+		}
+		archivePath := filepath.Join(basePath, relname)
+
+		isMatch, err := matchExcludes(archivePath, info.IsDir(), opts, gi)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if isMatch {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if isMatch {
+			return nil
+		}
+
+		if !a.preserveMetadata {
+			resolvedInfo, realPath, err := resolveSymlinkEntry(path, info, opts.ExcludeSymlinkDirectories)
+			if err != nil {
+				return err
+			}
+			if realPath != "" {
+				sub, err := a.dirSize(archivePath, realPath, opts, gi)
+				if err != nil {
+					return err
+				}
+				total += sub
+				return nil
+			}
+			info = resolvedInfo
+		}
+
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+func (a *ZipArchiver) createWalkFunc(basePath string, indirname string, opts ArchiveDirOpts, gi *gitignoreMatcher) func(path string, info os.FileInfo, err error) error {
 	return func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return fmt.Errorf("error encountered during file walk: %s", err)
@@ -128,7 +314,10 @@ func (a *ZipArchiver) createWalkFunc(basePath string, indirname string, opts Arc
 
 		archivePath := filepath.Join(basePath, relname)
 
-		isMatch := checkMatch(archivePath, opts.Excludes)
+		isMatch, err := matchExcludes(archivePath, info.IsDir(), opts, gi)
+		if err != nil {
+			return err
+		}
 
 		if info.IsDir() {
 			if isMatch {
@@ -146,23 +335,18 @@ func (a *ZipArchiver) createWalkFunc(basePath string, indirname string, opts Arc
 		}
 
 		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-			if !opts.ExcludeSymlinkDirectories {
-				realPath, err := filepath.EvalSymlinks(path)
-				if err != nil {
-					return err
-				}
-
-				realInfo, err := os.Stat(realPath)
-				if err != nil {
-					return err
-				}
-
-				if realInfo.IsDir() {
-					return filepath.Walk(realPath, a.createWalkFunc(archivePath, realPath, opts))
-				}
+			if a.preserveMetadata {
+				return a.archiveSymlink(archivePath, path, info)
+			}
 
-				info = realInfo
+			resolvedInfo, realPath, err := resolveSymlinkEntry(path, info, opts.ExcludeSymlinkDirectories)
+			if err != nil {
+				return err
 			}
+			if realPath != "" {
+				return filepath.Walk(realPath, a.createWalkFunc(archivePath, realPath, opts, gi))
+			}
+			info = resolvedInfo
 		}
 
 		fh, err := zip.FileInfoHeader(info)
@@ -173,7 +357,10 @@ func (a *ZipArchiver) createWalkFunc(basePath string, indirname string, opts Arc
 		fh.Method = zip.Deflate
 		// fh.Modified alone isn't enough when using a zero value
 		//nolint:staticcheck
-		fh.SetModTime(time.Time{})
+		fh.SetModTime(a.entryModTime(info))
+		if a.preserveMetadata {
+			fh.SetMode(info.Mode())
+		}
 
 		if a.outputFileMode != "" {
 			filemode, err := strconv.ParseUint(a.outputFileMode, 0, 32)
@@ -187,12 +374,26 @@ func (a *ZipArchiver) createWalkFunc(basePath string, indirname string, opts Arc
 		if err != nil {
 			return fmt.Errorf("error creating file inside archive: %s", err)
 		}
-		content, err := os.ReadFile(path)
+
+		a.acquireFileSlot()
+		src, err := os.Open(path)
 		if err != nil {
-			return fmt.Errorf("error reading file for archival: %s", err)
+			a.releaseFileSlot()
+			return fmt.Errorf("error opening file for archival: %s", err)
 		}
-		_, err = f.Write(content)
-		return err
+
+		n, err := a.copyWithBufferAndDigest(f, src, fh.Name)
+		src.Close()
+		a.releaseFileSlot()
+		if err != nil {
+			return fmt.Errorf("error writing file to archive: %s", err)
+		}
+
+		a.bytesWritten += n
+		if a.progressFunc != nil {
+			a.progressFunc(a.bytesWritten, a.bytesTotal, archivePath)
+		}
+		return nil
 	}
 }
 
@@ -212,14 +413,15 @@ func (a *ZipArchiver) ArchiveMultiple(content map[string][]byte) error {
 	sort.Strings(keys)
 
 	for _, filename := range keys {
-		f, err := a.writer.Create(filepath.ToSlash(filename))
+		name := filepath.ToSlash(filename)
+		f, err := a.writer.Create(name)
 		if err != nil {
 			return err
 		}
-		_, err = f.Write(content[filename])
-		if err != nil {
+		if _, err := f.Write(content[filename]); err != nil {
 			return err
 		}
+		a.recordDigest(name, content[filename])
 	}
 	return nil
 }
@@ -228,6 +430,124 @@ func (a *ZipArchiver) SetOutputFileMode(outputFileMode string) {
 	a.outputFileMode = outputFileMode
 }
 
+// SetProgressFunc registers fn to be called after each file ArchiveDir
+// writes, reporting cumulative bytes written against the total computed by
+// a pre-walk of the source directory.
+func (a *ZipArchiver) SetProgressFunc(fn ProgressFunc) {
+	a.progressFunc = fn
+}
+
+// SetMaxOpenFiles bounds the number of source files ArchiveDir may have
+// open at once, to avoid fd exhaustion on very wide trees. A value of 0
+// (the default) leaves the number of open files unbounded.
+func (a *ZipArchiver) SetMaxOpenFiles(maxOpenFiles int) {
+	a.maxOpenFiles = maxOpenFiles
+}
+
+func (a *ZipArchiver) acquireFileSlot() {
+	if a.openFilesSem != nil {
+		a.openFilesSem <- struct{}{}
+	}
+}
+
+func (a *ZipArchiver) releaseFileSlot() {
+	if a.openFilesSem != nil {
+		<-a.openFilesSem
+	}
+}
+
+// copyWithBuffer copies src into dst using a pooled buffer instead of
+// letting io.Copy allocate a fresh one per call.
+func (a *ZipArchiver) copyWithBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	bufp := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(bufp)
+	return io.CopyBuffer(dst, src, *bufp)
+}
+
+// copyWithBufferAndDigest behaves like copyWithBuffer, additionally hashing
+// the copied bytes as they stream so ComputeArchiveHash never has to reopen
+// the source file.
+func (a *ZipArchiver) copyWithBufferAndDigest(dst io.Writer, src io.Reader, name string) (int64, error) {
+	h := sha256.New()
+	n, err := a.copyWithBuffer(io.MultiWriter(dst, h), src)
+	if err != nil {
+		return n, err
+	}
+	a.entryDigests = append(a.entryDigests, entryDigest{name: name, sha256: h.Sum(nil), size: n})
+	return n, nil
+}
+
+// recordDigest hashes content and appends it to the entry digest list that
+// ComputeArchiveHash reads from.
+func (a *ZipArchiver) recordDigest(name string, content []byte) {
+	sum := sha256.Sum256(content)
+	a.entryDigests = append(a.entryDigests, entryDigest{name: name, sha256: sum[:], size: int64(len(content))})
+}
+
+// SetPreserveMetadata controls whether archived entries carry the real file
+// mode and modification time of their source, and whether symlinks are
+// written as symlink entries rather than being followed. When false (the
+// default), every entry gets a zeroed mtime so output hashes stay stable.
+func (a *ZipArchiver) SetPreserveMetadata(preserve bool) {
+	a.preserveMetadata = preserve
+}
+
+// SetSourceMtime pins every archived entry's modification time to mtime,
+// which must be an RFC3339 timestamp. This overrides both the zeroed
+// default and whatever SetPreserveMetadata(true) would otherwise copy from
+// the source file, so reproducible builds can still carry real file modes.
+func (a *ZipArchiver) SetSourceMtime(mtime string) error {
+	t, err := time.Parse(time.RFC3339, mtime)
+	if err != nil {
+		return fmt.Errorf("error parsing source_mtime value: %s", err)
+	}
+	a.sourceMtime = &t
+	return nil
+}
+
+// entryModTime returns the modification time to record for an entry built
+// from fi: the pinned source_mtime if one was set, the source file's real
+// mtime if preserve_metadata is enabled, or the zero value otherwise.
+func (a *ZipArchiver) entryModTime(fi os.FileInfo) time.Time {
+	if a.sourceMtime != nil {
+		return *a.sourceMtime
+	}
+	if a.preserveMetadata {
+		return fi.ModTime()
+	}
+	return time.Time{}
+}
+
+// archiveSymlink writes path, a symlink at archivePath, as a symlink entry
+// whose body is the link target, rather than dereferencing it.
+func (a *ZipArchiver) archiveSymlink(archivePath string, path string, info os.FileInfo) error {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return fmt.Errorf("error reading symlink target: %s", err)
+	}
+
+	fh, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("error creating file header: %s", err)
+	}
+	fh.Name = filepath.ToSlash(archivePath)
+	fh.Method = zip.Store
+	fh.SetModTime(a.entryModTime(info))
+	fh.SetMode(os.ModeSymlink | 0o777)
+
+	f, err := a.writer.CreateHeader(fh)
+	if err != nil {
+		return fmt.Errorf("error creating file inside archive: %s", err)
+	}
+
+	body := []byte(filepath.ToSlash(target))
+	if _, err := f.Write(body); err != nil {
+		return err
+	}
+	a.recordDigest(fh.Name, body)
+	return nil
+}
+
 func (a *ZipArchiver) open() error {
 	f, err := os.Create(a.filepath)
 	if err != nil {
@@ -235,6 +555,7 @@ func (a *ZipArchiver) open() error {
 	}
 	a.filewriter = f
 	a.writer = zip.NewWriter(f)
+	a.entryDigests = nil
 	return nil
 }
 
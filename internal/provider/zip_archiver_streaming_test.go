@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZipArchiver_ArchiveDir_ProgressFuncReportsTotalThroughSymlinkedDir(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	realDir := filepath.Join(dir, "real")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(realDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := make([]byte, 100000)
+	if err := os.WriteFile(filepath.Join(realDir, "big.txt"), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(realDir, filepath.Join(srcDir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(dir, "out.zip")
+	a := NewZipArchiver(zipPath).(*ZipArchiver)
+
+	var lastWritten, lastTotal int64
+	a.SetProgressFunc(func(bytesWritten, bytesTotal int64, currentPath string) {
+		lastWritten, lastTotal = bytesWritten, bytesTotal
+	})
+
+	if err := a.ArchiveDir(srcDir, ArchiveDirOpts{}); err != nil {
+		t.Fatalf("ArchiveDir() error = %v", err)
+	}
+
+	if lastTotal != int64(len(content)) {
+		t.Errorf("final bytesTotal = %d, want %d (the symlinked directory's file size)", lastTotal, len(content))
+	}
+	if lastWritten != lastTotal {
+		t.Errorf("final bytesWritten = %d, want it to equal bytesTotal = %d", lastWritten, lastTotal)
+	}
+}
+
+func TestZipArchiver_SetMaxOpenFiles_StillArchivesEveryFile(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"a.txt", "b.txt", "c.txt", "d.txt"}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	zipPath := filepath.Join(dir, "out.zip")
+	a := NewZipArchiver(zipPath).(*ZipArchiver)
+	a.SetMaxOpenFiles(1)
+	if err := a.ArchiveDir(srcDir, ArchiveDirOpts{}); err != nil {
+		t.Fatalf("ArchiveDir() error = %v", err)
+	}
+
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if len(r.File) != len(names) {
+		t.Errorf("expected %d entries, got %d", len(names), len(r.File))
+	}
+}
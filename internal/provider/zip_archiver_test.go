@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestZipArchiver_ArchiveDir_PreservesSymlinkWhenMetadataKept(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "real.txt"), []byte("real content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real.txt", filepath.Join(srcDir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(dir, "out.zip")
+	a := NewZipArchiver(zipPath).(*ZipArchiver)
+	a.SetPreserveMetadata(true)
+	if err := a.ArchiveDir(srcDir, ArchiveDirOpts{}); err != nil {
+		t.Fatalf("ArchiveDir() error = %v", err)
+	}
+
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name != "link.txt" {
+			continue
+		}
+		if f.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("link.txt mode = %v, want a symlink entry", f.Mode())
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		target, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(target) != "real.txt" {
+			t.Errorf("link.txt target = %q, want %q", target, "real.txt")
+		}
+		return
+	}
+	t.Fatal("link.txt entry not found")
+}
+
+func TestZipArchiver_SetSourceMtime_OverridesEntryModTime(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "source.txt")
+	if err := os.WriteFile(srcPath, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(dir, "out.zip")
+	a := NewZipArchiver(zipPath).(*ZipArchiver)
+	if err := a.SetSourceMtime("2020-01-02T03:04:05Z"); err != nil {
+		t.Fatalf("SetSourceMtime() error = %v", err)
+	}
+	if err := a.ArchiveFile(srcPath); err != nil {
+		t.Fatalf("ArchiveFile() error = %v", err)
+	}
+
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if len(r.File) != 1 {
+		t.Fatalf("expected exactly one entry, got %d", len(r.File))
+	}
+	want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if got := r.File[0].Modified.UTC(); !got.Equal(want) {
+		t.Errorf("entry ModTime = %v, want %v", got, want)
+	}
+}
+
+func TestZipArchiver_SetSourceMtime_InvalidValueErrors(t *testing.T) {
+	a := NewZipArchiver(filepath.Join(t.TempDir(), "out.zip")).(*ZipArchiver)
+	if err := a.SetSourceMtime("not-a-timestamp"); err == nil {
+		t.Fatal("expected an error for an invalid source_mtime, got nil")
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// canonicalDefaultFileMode is the mode recorded for canonical-mode entries
+// when output_file_mode is unset.
+const canonicalDefaultFileMode = os.FileMode(0o644)
+
+// entryDigest is the sha256 and size of one archived entry, recorded as
+// entries are written so ComputeArchiveHash can build a digest without
+// reopening the output file.
+type entryDigest struct {
+	name   string
+	sha256 []byte
+	size   int64
+}
+
+// SetCanonicalMode controls whether ArchiveDir sorts entries by
+// NFC-normalized forward-slash path and writes them with a fixed,
+// extra-field-free header, zeroed mtime and NFC-normalized entry name, so
+// output_sha/output_md5 are reproducible across machines regardless of
+// filesystem ordering or unicode normalization quirks. Entry names keep the
+// case they have on disk; case differences are not normalized.
+func (a *ZipArchiver) SetCanonicalMode(canonical bool) {
+	a.canonicalMode = canonical
+}
+
+// ComputeArchiveHash returns a content-addressed digest of the archive's
+// entries, independent of header bytes or entry order: "h1:" followed by
+// the base64 standard encoding of algo hashing a sorted manifest of
+// "<entry-digest>  <entry-name>\n" lines, mirroring the approach Go's
+// module zip tooling uses for its "h1:" digests. It streams the digests
+// recorded while writing the archive, so it never reopens the output file.
+func (a *ZipArchiver) ComputeArchiveHash(algo string) (string, error) {
+	if len(a.entryDigests) == 0 {
+		return "", fmt.Errorf("no archive entries to hash; call an Archive* method first")
+	}
+
+	h, err := newEntryHash(algo)
+	if err != nil {
+		return "", err
+	}
+
+	entries := make([]entryDigest, len(a.entryDigests))
+	copy(entries, a.entryDigests)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+
+	for _, e := range entries {
+		fmt.Fprintf(h, "%x  %s\n", e.sha256, e.name)
+	}
+
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func newEntryHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "", "sha256":
+		return sha256.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+}
+
+// canonicalSortKey is the key entries are ordered by in canonical mode: the
+// NFC-normalized, forward-slash archive path.
+func canonicalSortKey(archivePath string) string {
+	return norm.NFC.String(filepath.ToSlash(archivePath))
+}
+
+type canonicalDirEntry struct {
+	archivePath string
+	fullPath    string
+	info        os.FileInfo
+	isSymlink   bool
+}
+
+// archiveDirCanonical is ArchiveDir's canonical-mode path: it collects
+// every entry indirname would produce, sorts them by canonicalSortKey, and
+// writes them with a fixed header and zeroed mtime.
+func (a *ZipArchiver) archiveDirCanonical(indirname string, opts ArchiveDirOpts, gi *gitignoreMatcher) error {
+	var entries []canonicalDirEntry
+
+	if err := a.collectCanonicalEntries("", indirname, opts, gi, &entries); err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return canonicalSortKey(entries[i].archivePath) < canonicalSortKey(entries[j].archivePath)
+	})
+
+	a.bytesTotal = 0
+	for _, e := range entries {
+		if e.info.Mode().IsRegular() {
+			a.bytesTotal += e.info.Size()
+		}
+	}
+	a.bytesWritten = 0
+
+	if err := a.open(); err != nil {
+		return err
+	}
+	defer a.close()
+
+	for _, e := range entries {
+		if e.isSymlink {
+			target, err := os.Readlink(e.fullPath)
+			if err != nil {
+				return fmt.Errorf("error reading symlink target: %s", err)
+			}
+			if err := a.writeCanonicalEntry(e.archivePath, []byte(filepath.ToSlash(target)), os.ModeSymlink|0o777); err != nil {
+				return err
+			}
+			continue
+		}
+
+		content, err := os.ReadFile(e.fullPath)
+		if err != nil {
+			return fmt.Errorf("error reading file for archival: %s", err)
+		}
+
+		mode := canonicalDefaultFileMode
+		if a.outputFileMode != "" {
+			filemode, err := strconv.ParseUint(a.outputFileMode, 0, 32)
+			if err != nil {
+				return fmt.Errorf("error parsing output_file_mode value: %s", a.outputFileMode)
+			}
+			mode = os.FileMode(filemode)
+		}
+
+		if err := a.writeCanonicalEntry(e.archivePath, content, mode); err != nil {
+			return err
+		}
+
+		a.bytesWritten += int64(len(content))
+		if a.progressFunc != nil {
+			a.progressFunc(a.bytesWritten, a.bytesTotal, e.archivePath)
+		}
+	}
+	return nil
+}
+
+// collectCanonicalEntries walks dirname, appending the entries it would
+// produce to *entries with archive paths rooted at basePath. Symlinks are
+// written as a symlink entry when a.preserveMetadata is set, otherwise
+// dereferenced (recursing into a symlinked directory, or swapping in the
+// real file's info), as the non-canonical walker does. Unlike that walker,
+// when opts.ExcludeSymlinkDirectories is set (and a.preserveMetadata is not)
+// every symlink, to a file or a directory, is left out of the archive.
+func (a *ZipArchiver) collectCanonicalEntries(basePath, dirname string, opts ArchiveDirOpts, gi *gitignoreMatcher, entries *[]canonicalDirEntry) error {
+	return filepath.Walk(dirname, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relname, err := filepath.Rel(dirname, path)
+		if err != nil {
+			return err
+		}
+		archivePath := filepath.Join(basePath, relname)
+
+		isMatch, err := matchExcludes(archivePath, info.IsDir(), opts, gi)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if isMatch {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isMatch {
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink == os.ModeSymlink {
+			if a.preserveMetadata {
+				*entries = append(*entries, canonicalDirEntry{archivePath: archivePath, fullPath: path, info: info, isSymlink: true})
+				return nil
+			}
+
+			if opts.ExcludeSymlinkDirectories {
+				return nil
+			}
+
+			realPath, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return err
+			}
+
+			realInfo, err := os.Stat(realPath)
+			if err != nil {
+				return err
+			}
+
+			if realInfo.IsDir() {
+				return a.collectCanonicalEntries(archivePath, realPath, opts, gi, entries)
+			}
+
+			*entries = append(*entries, canonicalDirEntry{archivePath: archivePath, fullPath: realPath, info: realInfo})
+			return nil
+		}
+
+		*entries = append(*entries, canonicalDirEntry{archivePath: archivePath, fullPath: path, info: info})
+		return nil
+	})
+}
+
+// writeCanonicalEntry writes content under name's canonicalSortKey form
+// (NFC-normalized, forward-slash) with a fixed, extra-field-free header:
+// zeroed mtime and the given mode, then records its digest.
+func (a *ZipArchiver) writeCanonicalEntry(name string, content []byte, mode os.FileMode) error {
+	fh := &zip.FileHeader{
+		Name:   canonicalSortKey(name),
+		Method: zip.Deflate,
+	}
+	fh.SetModTime(time.Time{})
+	fh.SetMode(mode)
+
+	f, err := a.writer.CreateHeader(fh)
+	if err != nil {
+		return fmt.Errorf("error creating file inside archive: %s", err)
+	}
+	if _, err := f.Write(content); err != nil {
+		return err
+	}
+
+	a.recordDigest(fh.Name, content)
+	return nil
+}
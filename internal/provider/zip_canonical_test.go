@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZipArchiver_CanonicalMode_DereferencesSymlinksByDefault(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	realPath := filepath.Join(srcDir, "real.txt")
+	if err := os.WriteFile(realPath, []byte("real content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(realPath, filepath.Join(srcDir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(dir, "out.zip")
+	a := NewZipArchiver(zipPath).(*ZipArchiver)
+	a.SetCanonicalMode(true)
+	if err := a.ArchiveDir(srcDir, ArchiveDirOpts{}); err != nil {
+		t.Fatalf("ArchiveDir() error = %v", err)
+	}
+
+	content := readZipEntry(t, zipPath, "link.txt")
+	if content != "real content" {
+		t.Errorf("link.txt content = %q, want the dereferenced file's content, not a symlink target path", content)
+	}
+}
+
+func TestZipArchiver_CanonicalMode_PreservesSymlinkWhenMetadataKept(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	if err := os.Mkdir(srcDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	realPath := filepath.Join(srcDir, "real.txt")
+	if err := os.WriteFile(realPath, []byte("real content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real.txt", filepath.Join(srcDir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(dir, "out.zip")
+	a := NewZipArchiver(zipPath).(*ZipArchiver)
+	a.SetCanonicalMode(true)
+	a.SetPreserveMetadata(true)
+	if err := a.ArchiveDir(srcDir, ArchiveDirOpts{}); err != nil {
+		t.Fatalf("ArchiveDir() error = %v", err)
+	}
+
+	content := readZipEntry(t, zipPath, "link.txt")
+	if content != "real.txt" {
+		t.Errorf("link.txt content = %q, want the symlink target name %q", content, "real.txt")
+	}
+}
+
+// readZipEntry opens zipPath and returns the content of the entry named name.
+func readZipEntry(t *testing.T, zipPath, name string) string {
+	t.Helper()
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		buf := make([]byte, f.UncompressedSize64)
+		if _, err := io.ReadFull(rc, buf); err != nil {
+			t.Fatal(err)
+		}
+		return string(buf)
+	}
+	t.Fatalf("entry %q not found in %s", name, zipPath)
+	return ""
+}